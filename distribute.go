@@ -4,6 +4,8 @@ import (
 	"crypto/md5"
 	"encoding/binary"
 	"fmt"
+	"hash/maphash"
+	"math/bits"
 	"math/rand"
 	"os"
 	"time"
@@ -16,6 +18,16 @@ type Hasher interface {
 	Hash(input string) uint64
 }
 
+// SeededHasher is a Hasher that can be re-keyed with an explicit seed,
+// letting operators rotate the seed across deployments or tenants (to
+// defend against request IDs crafted to concentrate load on one group) or
+// derive independent hash spaces for layered routing decisions, all
+// without a code change.
+type SeededHasher interface {
+	Hasher
+	WithSeed(seed uint64) Hasher
+}
+
 // MD5Hasher implements the Hasher interface using MD5.
 type MD5Hasher struct{}
 
@@ -31,37 +43,207 @@ func (h XXHashHasher) Hash(input string) uint64 {
 	return xxhash.Sum64([]byte(input))
 }
 
+// MaphashHasher implements Hasher (and SeededHasher) using hash/maphash.
+// hash/maphash doesn't let callers choose its internal Seed value directly,
+// so the explicit uint64 seed is instead mixed into every input ahead of
+// the key; rotating the seed therefore rotates the mapping without needing
+// a new process-level maphash.Seed.
+type MaphashHasher struct {
+	mseed maphash.Seed
+	seed  uint64
+}
+
+// NewMaphashHasher returns a MaphashHasher keyed by seed. Within one process,
+// the same seed always produces the same mapping. The mapping is not stable
+// across processes or restarts: the shared maphash.Seed underneath it is
+// chosen at random when the process starts.
+func NewMaphashHasher(seed uint64) MaphashHasher {
+	return MaphashHasher{mseed: maphashSeed, seed: seed}
+}
+
+// maphashSeed is shared by every MaphashHasher created via NewMaphashHasher
+// so that two hashers with the same seed agree, rather than each picking an
+// independent random maphash.Seed.
+var maphashSeed = maphash.MakeSeed()
+
+func (h MaphashHasher) Hash(input string) uint64 {
+	var seedBuf [8]byte
+	binary.LittleEndian.PutUint64(seedBuf[:], h.seed)
+
+	var mh maphash.Hash
+	mh.SetSeed(h.mseed)
+	mh.Write(seedBuf[:])
+	mh.WriteString(input)
+	return mh.Sum64()
+}
+
+// WithSeed returns a MaphashHasher keyed by the new seed.
+func (h MaphashHasher) WithSeed(seed uint64) Hasher {
+	return MaphashHasher{mseed: h.mseed, seed: seed}
+}
+
+// NewRandomSeededHasher returns a MaphashHasher seeded with a fresh
+// process-random maphash.Seed, for in-process hashing (caches, rate-limit
+// buckets) where cross-process or cross-restart stability isn't required.
+func NewRandomSeededHasher() Hasher {
+	return MaphashHasher{mseed: maphash.MakeSeed()}
+}
+
 // DefaultHasher is the default hashing strategy (MD5).
 var DefaultHasher Hasher = XXHashHasher{}
 
-// RouteRequest determines the backend group based on request_id, distribution percentages, and hashing strategy.
-func RouteRequest(requestID string, distribution []int, hasher ...Hasher) (string, string) {
-	// Use the provided hasher or default to MD5Hasher
-	chosenHasher := DefaultHasher
-	if len(hasher) > 0 {
-		chosenHasher = hasher[0]
+// routeOptions holds the configurable parts of RouteRequest.
+type routeOptions struct {
+	hasher Hasher
+	domain string
+}
+
+// RouteOption configures RouteRequest.
+type RouteOption func(*routeOptions)
+
+// WithHasher selects the hashing strategy RouteRequest uses. Defaults to
+// DefaultHasher.
+func WithHasher(hasher Hasher) RouteOption {
+	return func(o *routeOptions) { o.hasher = hasher }
+}
+
+// WithDomain sets the domain separation tag RouteRequest feeds to its
+// (transient) Router. Two RouteRequest call sites sharing a Hasher but
+// using different domains (e.g. "region" vs. "shard") make statistically
+// independent placement decisions for the same request_id.
+func WithDomain(domain string) RouteOption {
+	return func(o *routeOptions) { o.domain = domain }
+}
+
+// defaultRouteDomain is the domain separation tag used when WithDomain is
+// not supplied.
+const defaultRouteDomain = "hash_distributer/route"
+
+// RouteRequest determines the backend group based on request_id, the
+// distribution percentages (or, more generally, weights), and the supplied
+// options. It is a thin, backward-compatible wrapper: each call builds a
+// transient Router out of distribution (group i becomes backend "Group i"
+// with weight distribution[i]) and routes through it. Router's ring size is
+// a fixed token budget split in proportion to weight (see
+// defaultVirtualNodes), not weight*virtualNodes, so building one stays cheap
+// regardless of whether distribution holds small percentages or large raw
+// weights.
+//
+// Callers who route many requests against a backend set that changes over
+// time (the case Router's ring exists for) should build and reuse a Router
+// directly instead of calling RouteRequest in a loop.
+func RouteRequest(requestID string, distribution []int, opts ...RouteOption) (string, string) {
+	cfg := routeOptions{hasher: DefaultHasher, domain: defaultRouteDomain}
+	for _, opt := range opts {
+		opt(&cfg)
 	}
 
-	// Step 1: Hash the request_id using the chosen hasher
-	hashValue := chosenHasher.Hash(requestID) % 100 // Map to a percentage range
+	backends := make([]Backend, len(distribution))
+	for i, weight := range distribution {
+		backends[i] = Backend{Name: fmt.Sprintf("Group %d", i+1), Weight: weight}
+	}
+
+	router := NewRouter(backends, WithRouterHasher(cfg.hasher), WithRouterDomain(cfg.domain))
+	group, hashString, _ := router.Route(requestID)
+	return group, hashString
+}
+
+// maxRejectionAttempts bounds how many times RouteRequestExact redraws from
+// the Expander before giving up on rejection sampling and accepting the
+// residual modulo bias of its last draw. At even a few percent of the value
+// space rejected per attempt, the chance of exhausting this many attempts is
+// astronomically small.
+const maxRejectionAttempts = 64
+
+// RouteRequestExact determines the backend group based on request_id and
+// distribution the same way RouteRequest does, but draws its randomness
+// through an ExpanderMD instead of Router's virtual-node ring. Where the
+// ring only approximates each group's share of traffic (the approximation
+// gets worse the fewer virtual nodes a group's weight works out to),
+// RouteRequestExact uses rejection sampling over the expanded bytes to pick
+// an index in [0, sum(distribution)) with no modulo bias, then walks the
+// cumulative distribution to find its group. That makes it the right choice
+// for distributions with many groups or weights that don't divide evenly
+// (e.g. 10000 equally-weighted groups), at the cost of a handful of extra
+// hash calls per lookup versus a single ring binary search.
+func RouteRequestExact(requestID string, distribution []int, opts ...RouteOption) (string, string) {
+	cfg := routeOptions{hasher: DefaultHasher, domain: defaultRouteDomain}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
 
-	// Convert hash to a readable hex string
-	hashString := fmt.Sprintf("%x", hashValue)
+	total := 0
+	for _, weight := range distribution {
+		total += weight
+	}
+	if total <= 0 {
+		return "Unknown Group", ""
+	}
+
+	expander := NewExpanderMD(cfg.hasher, cfg.domain)
+	byteLen := exactByteLen(total)
+	limit := exactRejectionLimit(byteLen, total)
+
+	var draw []byte
+	var value uint64
+	for attempt := 0; attempt < maxRejectionAttempts; attempt++ {
+		draw = expander.Expand([]byte(fmt.Sprintf("%s#%d", requestID, attempt)), byteLen)
+		value = bytesToUint64(draw)
+		if limit == 0 || value < limit {
+			break
+		}
+	}
 
-	// Step 2: Determine the group based on the cumulative distribution
+	target := int(value % uint64(total))
 	cumulative := 0
-	for index, percentage := range distribution {
-		cumulative += percentage
-		if int(hashValue) < cumulative {
-			return fmt.Sprintf("Group %d", index+1), hashString
+	for i, weight := range distribution {
+		cumulative += weight
+		if target < cumulative {
+			return fmt.Sprintf("Group %d", i+1), fmt.Sprintf("%x", draw)
 		}
 	}
+	return "Unknown Group", fmt.Sprintf("%x", draw)
+}
+
+// exactByteLen returns the number of bytes RouteRequestExact needs from its
+// Expander to represent a value space at least as large as total.
+func exactByteLen(total int) uint {
+	n := bits.Len64(uint64(total - 1))
+	if n == 0 {
+		n = 1
+	}
+	return uint((n + 7) / 8)
+}
+
+// exactRejectionLimit returns the largest multiple of total that fits in
+// byteLen bytes, the threshold rejection sampling redraws above to avoid
+// modulo bias. It returns 0 (meaning "don't reject") when byteLen spans the
+// full 64 bits: 1<<64 overflows uint64, and at that width the residual bias
+// from a plain modulo is negligible anyway.
+func exactRejectionLimit(byteLen uint, total int) uint64 {
+	if byteLen >= 8 {
+		return 0
+	}
+	space := uint64(1) << (8 * byteLen)
+	return space - space%uint64(total)
+}
 
-	return "Unknown Group", hashString // Fallback (should not occur with valid input)
+// bytesToUint64 interprets up to 8 big-endian bytes as a uint64, zero-padded
+// on the left when b is shorter than 8 bytes.
+func bytesToUint64(b []byte) uint64 {
+	var buf [8]byte
+	copy(buf[8-len(b):], b)
+	return binary.BigEndian.Uint64(buf[:])
 }
 
 // Test_Distribution tests a list of IDs and calculates the actual distribution.
-func Test_Distribution(ids []string, distribution []int, iterations int, hasher Hasher, outputFile *os.File) (map[string]int, bool) {
+// If hasher implements SeededHasher, it is re-keyed with seed before routing;
+// otherwise seed is ignored, since plain Hashers have no notion of a seed.
+func Test_Distribution(ids []string, distribution []int, iterations int, hasher Hasher, seed uint64, outputFile *os.File) (map[string]int, bool) {
+	if sh, ok := hasher.(SeededHasher); ok {
+		hasher = sh.WithSeed(seed)
+	}
+
 	overallGroupCounts := make(map[string]int)
 	multipleGroups := false
 
@@ -69,7 +251,7 @@ func Test_Distribution(ids []string, distribution []int, iterations int, hasher
 		groupCounts := make(map[string]int)
 		var hashString string
 		for i := 0; i < iterations; i++ {
-			group, hash := RouteRequest(id, distribution, hasher)
+			group, hash := RouteRequest(id, distribution, WithHasher(hasher))
 			hashString = hash
 			groupCounts[group]++
 			overallGroupCounts[group]++
@@ -141,7 +323,7 @@ func Test_SimpleDistribution(d1, d2 int) {
 	// Test distribution
 	distribution := []int{d1, d2}
 	fmt.Printf("\n===== Testing %d-%d Distribution =====\n", d1, d2)
-	groupCounts, multipleGroups := Test_Distribution(randomIDs, distribution, iterations, DefaultHasher, outputFile)
+	groupCounts, multipleGroups := Test_Distribution(randomIDs, distribution, iterations, DefaultHasher, 0, outputFile)
 	fmt.Printf("\nSummary of %d-%d Distribution:\n", d1, d2)
 	if count, exists := groupCounts["Group 1"]; exists {
 		fmt.Printf("Group 1: %d (%.2f%%)\n", count, float64(count)/(float64(numIDs)*float64(iterations))*100)
@@ -171,7 +353,7 @@ func Test_HashWithVariations(d1, d2 int) {
 
 		fmt.Printf("\n===== Testing %d-%d Distribution with ID Type: %s =====\n", d1, d2, idType)
 		ids := Test_GenerateRandomIDs(1000, idType) // Generate 1000 IDs of the current type
-		groupCounts, multipleGroups := Test_Distribution(ids, distribution, iterations, DefaultHasher, outputFile)
+		groupCounts, multipleGroups := Test_Distribution(ids, distribution, iterations, DefaultHasher, 0, outputFile)
 		fmt.Printf("\nSummary for ID Type: %s\n", idType)
 		if count, exists := groupCounts["Group 1"]; exists {
 			fmt.Printf("Group 1: %d (%.2f%%)\n", count, float64(count)/(float64(len(ids)*iterations))*100)
@@ -197,7 +379,7 @@ func Test_BenchmarkTiming(d1, d2 int) {
 		start := time.Now()         // Start timing
 		for i := 0; i < 1000; i++ { // Run 1000 iterations for benchmarking
 			for _, id := range ids {
-				RouteRequest(id, distribution, DefaultHasher)
+				RouteRequest(id, distribution, WithHasher(DefaultHasher))
 			}
 		}
 		duration := time.Since(start) // Calculate elapsed time
@@ -208,6 +390,106 @@ func Test_BenchmarkTiming(d1, d2 int) {
 	}
 }
 
+// Test_SeededDistribution exercises a SeededHasher across several seeds and
+// reports whether the distribution stays balanced as the seed rotates, the
+// property operators rely on when rotating seeds per deployment or tenant.
+func Test_SeededDistribution(d1, d2 int, seeds []uint64) {
+	rand.Seed(time.Now().UnixNano()) // Seed random number generator
+
+	outputFile, err := os.Create(fmt.Sprintf("Test3_SeededDistribution_%d_%d.output", d1, d2))
+	if err != nil {
+		fmt.Println("Error creating output file:", err)
+		return
+	}
+	defer outputFile.Close()
+
+	numIDs := 1000
+	iterations := 10
+	randomIDs := Test_GenerateRandomIDs(numIDs, "default")
+	distribution := []int{d1, d2}
+
+	fmt.Printf("\n===== Testing %d-%d Distribution Across Seeds =====\n", d1, d2)
+	for _, seed := range seeds {
+		groupCounts, multipleGroups := Test_Distribution(randomIDs, distribution, iterations, NewMaphashHasher(seed), seed, outputFile)
+		total := float64(numIDs * iterations)
+		fmt.Printf("\nSeed %d:\n", seed)
+		if count, exists := groupCounts["Group 1"]; exists {
+			fmt.Printf("Group 1: %d (%.2f%%)\n", count, float64(count)/total*100)
+		}
+		if count, exists := groupCounts["Group 2"]; exists {
+			fmt.Printf("Group 2: %d (%.2f%%)\n", count, float64(count)/total*100)
+		}
+		fmt.Printf("Is there any ID distributed to more than 1 group? %t\n", multipleGroups)
+	}
+}
+
+// Test_LayeredRouting shows two independent routing decisions derived from
+// the same Hasher and request_id, separated only by domain: a region pick
+// and a shard pick within it. Because the domains differ, the two decisions
+// are uncorrelated even though they share a Hasher.
+func Test_LayeredRouting(requestID string, regions, shards []int) {
+	region, _ := RouteRequest(requestID, regions, WithDomain("region"))
+	shard, _ := RouteRequest(requestID, shards, WithDomain("shard"))
+	fmt.Printf("\n===== Layered Routing for %q =====\n", requestID)
+	fmt.Printf("Region: %s, Shard: %s\n", region, shard)
+}
+
+// bucketRoute stands in for the original percentage-bucket scheme over
+// equally-weighted groups: the hash modulo numGroups picks the group, with
+// no ring and no memory of previous assignments. It exists only so Test_KeyMovement can
+// quantify how much more key movement it causes than Router's ring mode
+// when a backend is added.
+func bucketRoute(requestID string, numGroups int, hasher Hasher) string {
+	hashValue := hasher.Hash(requestID) % uint64(numGroups)
+	return fmt.Sprintf("Group %d", hashValue+1)
+}
+
+// Test_KeyMovement compares how many of numIDs random request IDs change
+// backend when a new, equally-weighted group is added, under the
+// percentage-bucket scheme (bucketRoute) versus Router's consistent-hash
+// ring. The bucket scheme moves about startGroups/(startGroups+1) of keys
+// (a key stays only if its hash has the same remainder modulo both group
+// counts, so ~80% move going from 4 to 5 groups). The ring moves the
+// 1/(startGroups+1) share the new group takes plus the keys on tokens the
+// existing groups give up when the token budget is re-split (about 28% going
+// from 4 to 5 groups with DefaultHasher).
+func Test_KeyMovement(numIDs, startGroups int, hasher Hasher) {
+	ids := Test_GenerateRandomIDs(numIDs, "default")
+
+	backends := make([]Backend, startGroups)
+	for i := range backends {
+		backends[i] = Backend{Name: fmt.Sprintf("Group %d", i+1), Weight: 1}
+	}
+	router := NewRouter(backends, WithRouterHasher(hasher))
+
+	bucketBefore := make(map[string]string, numIDs)
+	ringBefore := make(map[string]string, numIDs)
+	for _, id := range ids {
+		bucketBefore[id] = bucketRoute(id, startGroups, hasher)
+		group, _, done := router.Route(id)
+		ringBefore[id] = group
+		done()
+	}
+
+	router.Add(Backend{Name: fmt.Sprintf("Group %d", startGroups+1), Weight: 1})
+
+	bucketMoved, ringMoved := 0, 0
+	for _, id := range ids {
+		if bucketRoute(id, startGroups+1, hasher) != bucketBefore[id] {
+			bucketMoved++
+		}
+		group, _, done := router.Route(id)
+		done()
+		if group != ringBefore[id] {
+			ringMoved++
+		}
+	}
+
+	fmt.Printf("\n===== Key Movement After Adding a Backend (%d -> %d groups) =====\n", startGroups, startGroups+1)
+	fmt.Printf("Percentage-bucket mode: %.2f%% of keys moved\n", float64(bucketMoved)/float64(numIDs)*100)
+	fmt.Printf("Ring mode: %.2f%% of keys moved\n", float64(ringMoved)/float64(numIDs)*100)
+}
+
 func main() {
 	// Run Test_SimpleDistribution with different distributions
 	Test_SimpleDistribution(50, 50)
@@ -219,4 +501,16 @@ func main() {
 
 	// Run Test_BenchmarkTiming to benchmark processing times
 	Test_BenchmarkTiming(50, 50)
+
+	// Run Test_SeededDistribution to confirm seed rotation keeps the
+	// distribution balanced
+	Test_SeededDistribution(50, 50, []uint64{1, 2, 3, 42, 1337})
+
+	// Run Test_LayeredRouting to show region and shard picks staying
+	// independent under domain separation
+	Test_LayeredRouting("request-123", []int{1, 1, 1}, []int{1, 1, 1, 1, 1, 1, 1, 1, 1, 1})
+
+	// Run Test_KeyMovement to compare reshuffle rates when a backend is
+	// added under the old percentage-bucket scheme versus Router's ring
+	Test_KeyMovement(2000, 4, DefaultHasher)
 }
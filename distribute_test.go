@@ -0,0 +1,26 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/yschiang/hash_distributer/hashertest"
+)
+
+// TestHasherQuality runs the SMHasher-style suite against every Hasher
+// implementation shipped in this package, so a quality regression in
+// DefaultHasher or a newly added strategy fails CI instead of only showing
+// up as a skewed RouteRequest distribution in production.
+func TestHasherQuality(t *testing.T) {
+	hashers := map[string]hashertest.Hasher{
+		"MD5Hasher":     MD5Hasher{},
+		"XXHashHasher":  XXHashHasher{},
+		"MaphashHasher": NewMaphashHasher(1),
+	}
+
+	for name, h := range hashers {
+		h := h
+		t.Run(name, func(t *testing.T) {
+			hashertest.Run(t, h)
+		})
+	}
+}
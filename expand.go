@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// Expander deterministically stretches a base hash output into an
+// arbitrary-length pseudorandom byte string, modeled on the hash-to-curve
+// expand_message construction (RFC 9380, section 5.3). It lets
+// RouteRequestExact derive as many bits as a distribution needs instead of being capped by a
+// single Hasher's 64-bit output.
+type Expander interface {
+	// Expand returns a deterministic, pseudorandom byte string of the
+	// requested length, derived from in.
+	Expand(in []byte, length uint) []byte
+}
+
+// zPadSize is the size of the Z_pad block prepended to the first expanded
+// block. It mirrors the internal block size of common block hashes (MD5,
+// SHA-256); ExpanderMD doesn't introspect the wrapped Hasher for this, since
+// the Hasher interface only exposes a fixed-size digest.
+const zPadSize = 64
+
+// expanderDigestSize is the size in bytes of the digest produced by a
+// single call to the wrapped Hasher (Hash returns a uint64).
+const expanderDigestSize = 8
+
+// ExpanderMD implements Expander on top of any Hasher using the standard
+// block-structured expand_message construction: it hashes a zero-padded,
+// length- and domain-tagged message to get a seed block b_0, then computes
+// b_1 = H(b_0 || I2OSP(1,1) || dst_prime) and chains
+// b_i = H(b_{i-1} xor b_0 || I2OSP(i,1) || dst_prime) for i > 1 to produce
+// as many blocks as needed, truncated to the requested length.
+//
+// dst is the domain separation tag: two ExpanderMDs wrapping the same
+// Hasher but constructed with different dst values produce uncorrelated
+// output for the same input, which is what lets a single Hasher drive
+// independent routing decisions (e.g. region vs. shard vs. canary).
+type ExpanderMD struct {
+	hasher Hasher
+	dst    string
+}
+
+// NewExpanderMD returns an ExpanderMD wrapping hasher with the given domain
+// separation tag. dst must be at most 255 bytes (the construction encodes
+// its length in a single byte).
+func NewExpanderMD(hasher Hasher, dst string) ExpanderMD {
+	if len(dst) > 255 {
+		panic(fmt.Sprintf("expand: dst too long (%d bytes), must be <= 255", len(dst)))
+	}
+	return ExpanderMD{hasher: hasher, dst: dst}
+}
+
+func (e ExpanderMD) Expand(in []byte, length uint) []byte {
+	dstPrime := append(append([]byte(nil), e.dst...), byte(len(e.dst)))
+
+	var lenBuf [2]byte
+	binary.BigEndian.PutUint16(lenBuf[:], uint16(length))
+
+	msgPrime := concatBytes(make([]byte, zPadSize), in, lenBuf[:], []byte{0}, dstPrime)
+	b0 := e.block(msgPrime)
+
+	ell := (length + expanderDigestSize - 1) / expanderDigestSize
+	uniformBytes := make([]byte, 0, ell*expanderDigestSize)
+
+	// Per RFC 9380's expand_message_xmd, block 1 chains directly off b0;
+	// only blocks 2+ XOR b0 back into the previous block. Starting bPrev
+	// at b0 and XORing unconditionally (as an earlier version of this code
+	// did) makes block 1 depend on xorBytes(b0, b0) == an all-zero buffer,
+	// discarding the only block that depends on in.
+	bPrev := b0
+	for i := uint(1); i <= ell; i++ {
+		chained := b0
+		if i > 1 {
+			chained = xorBytes(b0, bPrev)
+		}
+		bi := e.block(concatBytes(chained, []byte{byte(i)}, dstPrime))
+		uniformBytes = append(uniformBytes, bi...)
+		bPrev = bi
+	}
+
+	return uniformBytes[:length]
+}
+
+// block runs the wrapped Hasher over data and returns its digest as a
+// big-endian byte slice.
+func (e ExpanderMD) block(data []byte) []byte {
+	var out [expanderDigestSize]byte
+	binary.BigEndian.PutUint64(out[:], e.hasher.Hash(string(data)))
+	return out[:]
+}
+
+func concatBytes(parts ...[]byte) []byte {
+	total := 0
+	for _, p := range parts {
+		total += len(p)
+	}
+	out := make([]byte, 0, total)
+	for _, p := range parts {
+		out = append(out, p...)
+	}
+	return out
+}
+
+func xorBytes(a, b []byte) []byte {
+	out := make([]byte, len(a))
+	for i := range a {
+		out[i] = a[i] ^ b[i]
+	}
+	return out
+}
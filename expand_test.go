@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"math/bits"
+	"testing"
+)
+
+func TestExpanderMDLength(t *testing.T) {
+	e := NewExpanderMD(XXHashHasher{}, "test")
+	for _, length := range []uint{0, 1, 7, 8, 9, 64, 257} {
+		out := e.Expand([]byte("input"), length)
+		if uint(len(out)) != length {
+			t.Fatalf("Expand(%d) returned %d bytes", length, len(out))
+		}
+	}
+}
+
+// TestExpanderMDSingleBlockDependsOnInput is a regression test for a bug
+// where block 1 unconditionally XORed b0 into itself (xorBytes(b0, b0) is
+// all zero), so every single-block Expand call — length <= 8, which is all
+// RouteRequestExact ever requests for realistic distributions — ignored in
+// entirely and returned the same output regardless of request ID.
+func TestExpanderMDSingleBlockDependsOnInput(t *testing.T) {
+	e := NewExpanderMD(XXHashHasher{}, "single-block")
+	for _, length := range []uint{1, 4, 8} {
+		a := e.Expand([]byte("input-a"), length)
+		b := e.Expand([]byte("input-b"), length)
+		if bytes.Equal(a, b) {
+			t.Fatalf("Expand(length=%d) returned identical output for different inputs", length)
+		}
+	}
+}
+
+func TestExpanderMDDeterministic(t *testing.T) {
+	e := NewExpanderMD(XXHashHasher{}, "test")
+	a := e.Expand([]byte("same-input"), 32)
+	b := e.Expand([]byte("same-input"), 32)
+	if !bytes.Equal(a, b) {
+		t.Fatal("Expand is not deterministic for identical input")
+	}
+}
+
+func TestExpanderMDDomainSeparation(t *testing.T) {
+	a := NewExpanderMD(XXHashHasher{}, "domain-a").Expand([]byte("input"), 32)
+	b := NewExpanderMD(XXHashHasher{}, "domain-b").Expand([]byte("input"), 32)
+	if bytes.Equal(a, b) {
+		t.Fatal("different dst values produced identical output")
+	}
+}
+
+func TestExpanderMDAvalanche(t *testing.T) {
+	e := NewExpanderMD(XXHashHasher{}, "avalanche")
+	base := e.Expand([]byte("avalanche-input-a"), 32)
+	flipped := e.Expand([]byte("avalanche-input-b"), 32)
+
+	diff := 0
+	for i := range base {
+		diff += bits.OnesCount8(base[i] ^ flipped[i])
+	}
+	// A one-character change in the input should flip roughly half the
+	// output bits; demand at least a third to catch a broken chain (e.g.
+	// one that forgets to mix b0 back into every block).
+	if diff < len(base)*8/3 {
+		t.Fatalf("only %d/%d bits differ for a changed input", diff, len(base)*8)
+	}
+}
+
+func TestExpanderMDTooLongDST(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected NewExpanderMD to panic on a dst over 255 bytes")
+		}
+	}()
+	NewExpanderMD(XXHashHasher{}, string(make([]byte, 256)))
+}
+
+func TestRouteRequestExactUniformity(t *testing.T) {
+	const groups = 50
+	const iterations = 20000
+	distribution := make([]int, groups)
+	for i := range distribution {
+		distribution[i] = 1
+	}
+
+	counts := make(map[string]int, groups)
+	for i := 0; i < iterations; i++ {
+		group, _ := RouteRequestExact(fmt.Sprintf("request-%d", i), distribution)
+		counts[group]++
+	}
+
+	if len(counts) != groups {
+		t.Fatalf("expected all %d groups to receive traffic, got %d", groups, len(counts))
+	}
+	expected := float64(iterations) / float64(groups)
+	for group, count := range counts {
+		if deviation := math.Abs(float64(count)-expected) / expected; deviation > 0.25 {
+			t.Fatalf("%s got %d requests, expected ~%.0f (%.0f%% off)", group, count, expected, deviation*100)
+		}
+	}
+}
+
+func TestRouteRequestExactRespectsWeights(t *testing.T) {
+	const iterations = 20000
+	distribution := []int{90, 10}
+	counts := map[string]int{}
+	for i := 0; i < iterations; i++ {
+		group, _ := RouteRequestExact(fmt.Sprintf("weighted-%d", i), distribution)
+		counts[group]++
+	}
+
+	got := float64(counts["Group 1"]) / float64(iterations) * 100
+	if got < 85 || got > 95 {
+		t.Fatalf("Group 1 got %.2f%% of traffic, want ~90%%", got)
+	}
+}
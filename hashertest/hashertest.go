@@ -0,0 +1,342 @@
+// Package hashertest provides SMHasher-style quality tests for implementations
+// of the hash_distributer Hasher interface. It is meant to be driven from a
+// regular Go test, e.g.:
+//
+//	func TestMD5HasherQuality(t *testing.T) {
+//		hashertest.Run(t, MD5Hasher{})
+//	}
+//
+// The suite does not depend on hash_distributer's package main; any type with
+// a `Hash(string) uint64` method satisfies the Hasher interface below and can
+// be tested.
+package hashertest
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+// Hasher is the method set hashertest needs from a hashing strategy. It is
+// structurally identical to hash_distributer's Hasher interface, so any
+// implementation of that interface (MD5Hasher, XXHashHasher, ...) satisfies
+// it without an explicit dependency.
+type Hasher interface {
+	Hash(input string) uint64
+}
+
+// Config controls the sample sizes and statistical thresholds used by the
+// suite. DefaultConfig returns the values Run uses; RunWithConfig and the
+// individual checks use a Config exactly as given, so a zero-valued field is
+// not replaced by its default.
+type Config struct {
+	// AvalancheKeys is the number of random keys sampled per input bit in
+	// the avalanche test.
+	AvalancheKeys int
+	// AvalancheEpsilon is the maximum allowed deviation of an output bit's
+	// flip probability from 0.5.
+	AvalancheEpsilon float64
+
+	// CollisionKeysPerFamily is how many keys are generated for each
+	// structured key family in the collision test.
+	CollisionKeysPerFamily int
+
+	// UniformityBuckets lists the B values Hash(id) % B is binned into
+	// for the chi-squared uniformity test. Includes non-power-of-two
+	// values since those are the ones RouteRequest-style modulo splits
+	// actually rely on.
+	UniformityBuckets []int
+	// UniformitySamples is the number of random IDs hashed per bucket
+	// count in the uniformity test.
+	UniformitySamples int
+
+	// Alpha is the significance level used for the chi-squared uniformity
+	// and collision checks: a test fails only if the observed statistic
+	// exceeds the (1-Alpha) critical value.
+	Alpha float64
+}
+
+// DefaultConfig returns the thresholds used when Run is called directly.
+func DefaultConfig() Config {
+	return Config{
+		AvalancheKeys:          512,
+		AvalancheEpsilon:       0.06,
+		CollisionKeysPerFamily: 20000,
+		UniformityBuckets:      []int{2, 3, 5, 7, 16, 37, 100, 251},
+		UniformitySamples:      200000,
+		Alpha:                  0.01,
+	}
+}
+
+// Run executes the full suite against h using DefaultConfig, registering each
+// check as a subtest.
+func Run(t *testing.T, h Hasher) {
+	RunWithConfig(t, h, DefaultConfig())
+}
+
+// RunWithConfig executes the full suite against h using cfg, registering each
+// check as a subtest.
+func RunWithConfig(t *testing.T, h Hasher, cfg Config) {
+	t.Run("Sanity", func(t *testing.T) { Sanity(t, h) })
+	t.Run("Avalanche", func(t *testing.T) { Avalanche(t, h, cfg) })
+	t.Run("Collision", func(t *testing.T) { Collision(t, h, cfg) })
+	t.Run("Uniformity", func(t *testing.T) { Uniformity(t, h, cfg) })
+}
+
+// Sanity asserts that h.Hash(key) depends only on the bytes of key, not on
+// bytes surrounding it or its alignment within a larger buffer. This catches
+// implementations that read past slice bounds (e.g. block-based hashing that
+// over-reads the final partial block).
+func Sanity(t *testing.T, h Hasher) {
+	keys := []string{
+		"",
+		"a",
+		"request-42",
+		strings.Repeat("k", 63),
+		strings.Repeat("k", 64),
+		strings.Repeat("k", 65),
+	}
+
+	for _, key := range keys {
+		want := h.Hash(key)
+
+		// Embed key at a range of offsets inside a larger buffer and slice
+		// it back out, so the underlying array differs in both alignment
+		// and surrounding content.
+		for _, offset := range []int{0, 1, 3, 7, 8, 15, 16, 31} {
+			prefix := strings.Repeat("\xAA", offset)
+			suffix := strings.Repeat("\x55", offset+1)
+			buf := prefix + key + suffix
+			got := h.Hash(buf[offset : offset+len(key)])
+			if got != want {
+				t.Fatalf("Hash(%q) = %d when embedded at offset %d (surrounded by %q), want %d (unembedded)",
+					key, got, offset, prefix+"..."+suffix, want)
+			}
+		}
+	}
+}
+
+// Avalanche flips each bit of N random keys and checks that each output bit
+// flips with probability 0.5 +/- cfg.AvalancheEpsilon, the hallmark of a
+// well-mixing hash (SMHasher's "avalanche" test).
+func Avalanche(t *testing.T, h Hasher, cfg Config) {
+	const keyLen = 16 // bytes
+	flips := make([]int, 64)
+	trials := 0
+
+	rng := rand.New(rand.NewSource(1))
+	buf := make([]byte, keyLen)
+	for n := 0; n < cfg.AvalancheKeys; n++ {
+		rng.Read(buf)
+		base := h.Hash(string(buf))
+
+		for byteIdx := range buf {
+			for bit := 0; bit < 8; bit++ {
+				flipped := append([]byte(nil), buf...)
+				flipped[byteIdx] ^= 1 << bit
+				out := base ^ h.Hash(string(flipped))
+				for outBit := 0; outBit < 64; outBit++ {
+					if out&(1<<outBit) != 0 {
+						flips[outBit]++
+					}
+				}
+				trials++
+			}
+		}
+	}
+
+	for outBit, count := range flips {
+		p := float64(count) / float64(trials)
+		if math.Abs(p-0.5) > cfg.AvalancheEpsilon {
+			t.Errorf("output bit %d flipped with probability %.4f over %d trials, want 0.5 +/- %.4f",
+				outBit, p, trials, cfg.AvalancheEpsilon)
+		}
+	}
+}
+
+// Collision generates several families of structurally related keys and
+// checks that the number of full 64-bit collisions observed does not exceed
+// the birthday-paradox expectation k(k-1)/2 * 2^-64 by more than would be
+// expected at the given significance level under a Poisson model.
+func Collision(t *testing.T, h Hasher, cfg Config) {
+	families := map[string][]string{
+		"sparse-bits":     sparseBitKeys(cfg.CollisionKeysPerFamily),
+		"sequential-int":  sequentialIntKeys(cfg.CollisionKeysPerFamily),
+		"short-ascii":     shortASCIIKeys(cfg.CollisionKeysPerFamily),
+		"edit-distance-2": editDistanceKeys(cfg.CollisionKeysPerFamily),
+	}
+
+	for name, keys := range families {
+		k := float64(len(keys))
+		expected := k * (k - 1) / 2 / math.Pow(2, 64)
+		// Poisson(expected) tail bound: for a rare-event count, the
+		// (1-alpha) critical value is well approximated by
+		// expected + z*sqrt(expected) + a small additive margin that
+		// dominates when expected is near zero.
+		bound := expected + invNormCDF(1-cfg.Alpha)*math.Sqrt(expected) + 3
+
+		seen := make(map[uint64]int, len(keys))
+		for _, key := range keys {
+			seen[h.Hash(key)]++
+		}
+		observed := 0.0
+		for _, count := range seen {
+			if count > 1 {
+				observed += float64(count * (count - 1) / 2)
+			}
+		}
+
+		if observed > bound {
+			t.Errorf("family %q: observed %d collisions among %d keys, expected ~%.4f (bound %.4f at alpha=%.3f)",
+				name, int(observed), len(keys), expected, bound, cfg.Alpha)
+		}
+	}
+}
+
+// Uniformity bins Hash(id) % B over random IDs for each configured B
+// (including non-power-of-two values, since that's what percentage-based
+// routing relies on) and fails if the chi-squared statistic for the bin
+// counts exceeds the critical value for B-1 degrees of freedom.
+func Uniformity(t *testing.T, h Hasher, cfg Config) {
+	rng := rand.New(rand.NewSource(2))
+
+	for _, b := range cfg.UniformityBuckets {
+		buckets := make([]int, b)
+		for i := 0; i < cfg.UniformitySamples; i++ {
+			id := randomID(rng)
+			buckets[h.Hash(id)%uint64(b)]++
+		}
+
+		expected := float64(cfg.UniformitySamples) / float64(b)
+		chi2 := 0.0
+		for _, count := range buckets {
+			diff := float64(count) - expected
+			chi2 += diff * diff / expected
+		}
+
+		critical := chiSquaredCritical(b-1, cfg.Alpha)
+		if chi2 > critical {
+			t.Errorf("B=%d: chi-squared statistic %.2f exceeds critical value %.2f (df=%d, alpha=%.3f)",
+				b, chi2, critical, b-1, cfg.Alpha)
+		}
+	}
+}
+
+// sparseBitKeys generates keys that are all-zero except for one or two set
+// bits, the classic SMHasher "sparse" family that shakes out hashers with
+// weak mixing of low-popcount inputs. Several byte widths are combined so
+// the family has enough distinct keys to satisfy large values of n without
+// repeating a key (a repeated key would produce a guaranteed, meaningless
+// "collision").
+func sparseBitKeys(n int) []string {
+	keys := make([]string, 0, n)
+	for _, width := range []int{2, 4, 8, 16, 32} {
+		bits := width * 8
+		buf := make([]byte, width)
+		for lo := 0; lo < bits && len(keys) < n; lo++ {
+			for hi := lo; hi < bits && len(keys) < n; hi++ {
+				for i := range buf {
+					buf[i] = 0
+				}
+				buf[lo/8] |= 1 << (lo % 8)
+				buf[hi/8] |= 1 << (hi % 8)
+				keys = append(keys, string(buf))
+			}
+		}
+	}
+	return keys[:n]
+}
+
+func sequentialIntKeys(n int) []string {
+	keys := make([]string, n)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("%d", i)
+	}
+	return keys
+}
+
+func shortASCIIKeys(n int) []string {
+	const alphabet = "abcdefghijklmnopqrstuvwxyz0123456789"
+	keys := make([]string, 0, n)
+	for a := 0; a < len(alphabet) && len(keys) < n; a++ {
+		for b := 0; b < len(alphabet) && len(keys) < n; b++ {
+			for c := 0; c < len(alphabet) && len(keys) < n; c++ {
+				keys = append(keys, string([]byte{alphabet[a], alphabet[b], alphabet[c]}))
+			}
+		}
+	}
+	return keys[:n]
+}
+
+// editDistanceKeys generates keys that differ from a fixed base string by
+// exactly two byte edits, the pattern most likely to expose a hash that
+// fails to mix small, localized input changes. Candidates are deduplicated
+// so a repeated key never masquerades as a "collision".
+func editDistanceKeys(n int) []string {
+	base := []byte(strings.Repeat("the-quick-brown-fox-jumps-over-the-lazy-dog-", 6))
+	seen := make(map[string]bool, n)
+	keys := make([]string, 0, n)
+	for i := 0; len(keys) < n && i < n*4; i++ {
+		b := append([]byte(nil), base...)
+		pos1 := i % len(b)
+		pos2 := (i*7 + 13) % len(b)
+		if pos2 == pos1 {
+			pos2 = (pos2 + 1) % len(b)
+		}
+		// Both XOR masks lie in [1, 255], so both positions really change.
+		b[pos1] ^= byte(i%255) + 1
+		b[pos2] ^= byte(i/255%255) + 1
+		key := string(b)
+		if !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+func randomID(rng *rand.Rand) string {
+	buf := make([]byte, 12)
+	rng.Read(buf)
+	return string(buf)
+}
+
+// invNormCDF approximates the inverse standard normal CDF (quantile
+// function) using Peter Acklam's rational approximation. Good to ~1e-9 over
+// (0, 1), which is far more precision than the bounds above need.
+func invNormCDF(p float64) float64 {
+	a := [...]float64{-3.969683028665376e+01, 2.209460984245205e+02, -2.759285104469687e+02, 1.383577518672690e+02, -3.066479806614716e+01, 2.506628277459239e+00}
+	b := [...]float64{-5.447609879822406e+01, 1.615858368580409e+02, -1.556989798598866e+02, 6.680131188771972e+01, -1.328068155288572e+01}
+	c := [...]float64{-7.784894002430293e-03, -3.223964580411365e-01, -2.400758277161838e+00, -2.549732539343734e+00, 4.374664141464968e+00, 2.938163982698783e+00}
+	d := [...]float64{7.784695709041462e-03, 3.224671290700398e-01, 2.445134137142996e+00, 3.754408661907416e+00}
+
+	const low, high = 0.02425, 1 - 0.02425
+	switch {
+	case p < low:
+		q := math.Sqrt(-2 * math.Log(p))
+		return (((((c[0]*q+c[1])*q+c[2])*q+c[3])*q+c[4])*q + c[5]) /
+			((((d[0]*q+d[1])*q+d[2])*q+d[3])*q + 1)
+	case p <= high:
+		q := p - 0.5
+		r := q * q
+		return (((((a[0]*r+a[1])*r+a[2])*r+a[3])*r+a[4])*r + a[5]) * q /
+			(((((b[0]*r+b[1])*r+b[2])*r+b[3])*r+b[4])*r + 1)
+	default:
+		q := math.Sqrt(-2 * math.Log(1-p))
+		return -(((((c[0]*q+c[1])*q+c[2])*q+c[3])*q+c[4])*q + c[5]) /
+			((((d[0]*q+d[1])*q+d[2])*q+d[3])*q + 1)
+	}
+}
+
+// chiSquaredCritical approximates the (1-alpha) critical value of the
+// chi-squared distribution with df degrees of freedom using the
+// Wilson-Hilferty cube-root transformation, which is accurate to within
+// about 1% for df >= 1.
+func chiSquaredCritical(df int, alpha float64) float64 {
+	d := float64(df)
+	z := invNormCDF(1 - alpha)
+	x := 1 - 2/(9*d) + z*math.Sqrt(2/(9*d))
+	return d * x * x * x
+}
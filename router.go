@@ -0,0 +1,281 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+// Backend is a named routing target with a weight relative to its peers.
+type Backend struct {
+	Name   string
+	Weight int
+}
+
+// Done releases the in-flight slot a bounded-load Route claimed for a
+// backend. It is a no-op when bounded-load tracking isn't enabled.
+type Done func()
+
+// routerOptions holds the configurable parts of a Router.
+type routerOptions struct {
+	hasher       Hasher
+	virtualNodes int
+	domain       string
+	loadEpsilon  float64
+	boundedLoad  bool
+}
+
+// RouterOption configures a Router.
+type RouterOption func(*routerOptions)
+
+// defaultVirtualNodes is the approximate total number of ring tokens placed
+// across all backends (split in proportion to weight) when WithVirtualNodes
+// isn't supplied. Higher counts trade ring-build cost for smoother load
+// distribution across backends. It is independent of the backends' raw
+// weight magnitude: {50, 50} and {5000, 5000} build the same size ring.
+const defaultVirtualNodes = 1000
+
+// WithRouterHasher selects the hashing strategy used to place ring tokens
+// and look up requests. Defaults to DefaultHasher.
+func WithRouterHasher(hasher Hasher) RouterOption {
+	return func(o *routerOptions) { o.hasher = hasher }
+}
+
+// WithVirtualNodes sets the approximate total number of ring tokens placed
+// across all backends, split in proportion to weight.
+func WithVirtualNodes(n int) RouterOption {
+	return func(o *routerOptions) { o.virtualNodes = n }
+}
+
+// WithRouterDomain namespaces every hash the Router computes (both ring
+// tokens and lookups), the same way RouteOption's WithDomain does for
+// RouteRequest: two Routers sharing a Hasher but different domains place
+// uncorrelated rings.
+func WithRouterDomain(domain string) RouterOption {
+	return func(o *routerOptions) { o.domain = domain }
+}
+
+// WithLoadEpsilon enables Google's "consistent hashing with bounded loads":
+// Route will skip a backend whose in-flight count has reached its capacity,
+// ceil((1+epsilon) * average load) scaled by the backend's weight relative
+// to the mean weight, and probe forward on the ring instead.
+func WithLoadEpsilon(epsilon float64) RouterOption {
+	return func(o *routerOptions) { o.loadEpsilon = epsilon; o.boundedLoad = true }
+}
+
+// Router maps request IDs to weighted backends using consistent hashing, so
+// adding or removing a backend reshuffles a minority of keys instead of the
+// ~100% a percentage-bucket scheme reshuffles whenever a weight changes.
+// Because the fixed token budget (see defaultVirtualNodes) is re-split in
+// proportion to weight on every change, the keys moved are not only the ~1/N
+// share the added (or removed) backend takes (or frees): existing backends
+// also lose or gain tokens, and the keys on those tokens move as well.
+type Router struct {
+	opts routerOptions
+
+	mu       sync.RWMutex
+	backends map[string]int    // name -> weight
+	tokens   []uint64          // sorted ring positions
+	owners   []string          // owners[i] owns tokens[i]
+	load     map[string]*int64 // name -> in-flight count, atomic
+}
+
+// NewRouter builds a Router over backends.
+func NewRouter(backends []Backend, opts ...RouterOption) *Router {
+	cfg := routerOptions{hasher: DefaultHasher, virtualNodes: defaultVirtualNodes}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	r := &Router{
+		opts:     cfg,
+		backends: make(map[string]int, len(backends)),
+		load:     make(map[string]*int64, len(backends)),
+	}
+	for _, b := range backends {
+		r.backends[b.Name] = b.Weight
+		r.load[b.Name] = new(int64)
+	}
+	r.rebuild()
+	return r
+}
+
+// Add inserts backend, or updates its weight if it already exists, and
+// rebuilds the ring.
+func (r *Router) Add(backend Backend) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.backends[backend.Name] = backend.Weight
+	if _, ok := r.load[backend.Name]; !ok {
+		r.load[backend.Name] = new(int64)
+	}
+	r.rebuildLocked()
+}
+
+// Remove drops a backend and rebuilds the ring.
+func (r *Router) Remove(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.backends, name)
+	delete(r.load, name)
+	r.rebuildLocked()
+}
+
+func (r *Router) rebuildLocked() {
+	type token struct {
+		pos   uint64
+		owner string
+	}
+
+	var totalWeight int
+	for _, weight := range r.backends {
+		totalWeight += weight
+	}
+
+	tokens := make([]token, 0, r.virtualNodesLocked())
+	if totalWeight > 0 {
+		vn := r.virtualNodesLocked()
+		for name, weight := range r.backends {
+			// Split the token budget in proportion to weight rather than
+			// multiplying weight directly by vn: otherwise a distribution
+			// like {50, 50} (percentages, not unit weights) would build a
+			// ring with 50*vn tokens per backend instead of vn total.
+			count := int(math.Round(float64(vn) * float64(weight) / float64(totalWeight)))
+			if weight > 0 && count < 1 {
+				count = 1
+			}
+			for i := 0; i < count; i++ {
+				tokens = append(tokens, token{pos: r.opts.hasher.Hash(r.tokenKey(name, i)), owner: name})
+			}
+		}
+	}
+	sort.Slice(tokens, func(i, j int) bool { return tokens[i].pos < tokens[j].pos })
+
+	r.tokens = make([]uint64, len(tokens))
+	r.owners = make([]string, len(tokens))
+	for i, t := range tokens {
+		r.tokens[i] = t.pos
+		r.owners[i] = t.owner
+	}
+}
+
+func (r *Router) rebuild() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.rebuildLocked()
+}
+
+func (r *Router) virtualNodesLocked() int {
+	if r.opts.virtualNodes <= 0 {
+		return defaultVirtualNodes
+	}
+	return r.opts.virtualNodes
+}
+
+// tokenKey is the string hashed to place (or look up) a ring token for
+// backend name's i-th virtual node, namespaced by the Router's domain.
+func (r *Router) tokenKey(name string, i int) string {
+	if r.opts.domain == "" {
+		return name + "#" + strconv.Itoa(i)
+	}
+	return r.opts.domain + "|" + name + "#" + strconv.Itoa(i)
+}
+
+// queryKey is the string hashed to look up requestID's position on the
+// ring, namespaced the same way tokenKey is.
+func (r *Router) queryKey(requestID string) string {
+	if r.opts.domain == "" {
+		return requestID
+	}
+	return r.opts.domain + "|" + requestID
+}
+
+// Route hashes requestID and walks clockwise from its position on the ring
+// to the next token, returning the owning backend's name and a hex digest
+// of the lookup hash. If bounded-load tracking is enabled (WithLoadEpsilon),
+// a backend whose in-flight count has already reached its capacity (see
+// capacityLocked) is skipped in favor of the next token clockwise, and the
+// returned Done must
+// be called once the request finishes to release its claimed slot.
+func (r *Router) Route(requestID string) (string, string, Done) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if len(r.tokens) == 0 {
+		return "Unknown Group", "", func() {}
+	}
+
+	hash := r.opts.hasher.Hash(r.queryKey(requestID))
+	hashString := fmt.Sprintf("%x", hash)
+
+	n := len(r.tokens)
+	start := sort.Search(n, func(i int) bool { return r.tokens[i] >= hash })
+
+	bounded, avg, meanWeight := r.loadStatsLocked()
+	if !bounded {
+		return r.owners[start%n], hashString, func() {}
+	}
+	for i := 0; i < n; i++ {
+		idx := (start + i) % n
+		name := r.owners[idx]
+		counter := r.load[name]
+		if atomic.LoadInt64(counter) < r.capacityLocked(name, avg, meanWeight) {
+			atomic.AddInt64(counter, 1)
+			return name, hashString, func() { atomic.AddInt64(counter, -1) }
+		}
+	}
+
+	// Every backend is at or over capacity (can happen transiently under a
+	// very tight epsilon); route to the first candidate rather than drop
+	// the request.
+	name := r.owners[start%n]
+	counter := r.load[name]
+	atomic.AddInt64(counter, 1)
+	return name, hashString, func() { atomic.AddInt64(counter, -1) }
+}
+
+// loadStatsLocked returns whether bounded-load checking is enabled and, if
+// so, the average in-flight load across backends and their average weight,
+// both needed by capacityLocked to scale a backend's cap by its own weight.
+// Must be called with r.mu held.
+func (r *Router) loadStatsLocked() (bounded bool, avg, meanWeight float64) {
+	if !r.opts.boundedLoad || len(r.backends) == 0 {
+		return false, 0, 0
+	}
+
+	var total int64
+	for _, c := range r.load {
+		total += atomic.LoadInt64(c)
+	}
+	var totalWeight int
+	for _, w := range r.backends {
+		totalWeight += w
+	}
+
+	n := float64(len(r.backends))
+	return true, float64(total) / n, float64(totalWeight) / n
+}
+
+// capacityLocked returns the current load cap for backend name, scaled by
+// its weight relative to meanWeight: a backend weighted twice the average
+// gets roughly twice the in-flight capacity, so bounded-load skipping
+// doesn't defeat weighted routing by capping every backend equally
+// regardless of how much traffic it's meant to absorb. Must be called with
+// r.mu held.
+func (r *Router) capacityLocked(name string, avg, meanWeight float64) int64 {
+	scale := 1.0
+	if weight := float64(r.backends[name]); meanWeight > 0 && weight > 0 {
+		scale = weight / meanWeight
+	}
+
+	capacity := int64(math.Ceil((1 + r.opts.loadEpsilon) * avg * scale))
+	if capacity < 1 {
+		capacity = 1
+	}
+	return capacity
+}
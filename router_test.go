@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestRouterAddRemove(t *testing.T) {
+	r := NewRouter([]Backend{{Name: "a", Weight: 1}})
+
+	group, _, done := r.Route("x")
+	done()
+	if group != "a" {
+		t.Fatalf("expected sole backend %q, got %q", "a", group)
+	}
+
+	r.Remove("a")
+	group, _, done = r.Route("x")
+	done()
+	if group != "Unknown Group" {
+		t.Fatalf("expected Unknown Group after removing the only backend, got %q", group)
+	}
+}
+
+// TestRouterWeightProportionalDistribution checks that Route's ring splits
+// traffic in proportion to backend weight, the property rebuildLocked's
+// weight/totalWeight token allocation is responsible for.
+func TestRouterWeightProportionalDistribution(t *testing.T) {
+	r := NewRouter([]Backend{{Name: "Group 1", Weight: 75}, {Name: "Group 2", Weight: 25}}, WithRouterHasher(XXHashHasher{}))
+
+	const iterations = 20000
+	counts := make(map[string]int, 2)
+	for i := 0; i < iterations; i++ {
+		group, _, done := r.Route(fmt.Sprintf("id-%d", i))
+		done()
+		counts[group]++
+	}
+
+	got := float64(counts["Group 1"]) / float64(iterations) * 100
+	if got < 70 || got > 80 {
+		t.Fatalf("Group 1 got %.2f%% of traffic, want ~75%%", got)
+	}
+}
+
+// TestRouterAddMinimizesKeyMovement is an assertion-based version of
+// distribute.go's print-only Test_KeyMovement: adding a backend to the ring
+// should reshuffle at most twice the 1/(startGroups+1) share the new backend
+// takes (existing backends also give up tokens when the ring's budget is
+// re-split), not the startGroups/(startGroups+1) (~80% here) that
+// Test_KeyMovement's modulo bucket scheme moves.
+func TestRouterAddMinimizesKeyMovement(t *testing.T) {
+	const numIDs = 5000
+	const startGroups = 4
+	ids := Test_GenerateRandomIDs(numIDs, "default")
+
+	backends := make([]Backend, startGroups)
+	for i := range backends {
+		backends[i] = Backend{Name: fmt.Sprintf("Group %d", i+1), Weight: 1}
+	}
+	r := NewRouter(backends, WithRouterHasher(DefaultHasher))
+
+	before := make(map[string]string, numIDs)
+	for _, id := range ids {
+		group, _, done := r.Route(id)
+		done()
+		before[id] = group
+	}
+
+	r.Add(Backend{Name: fmt.Sprintf("Group %d", startGroups+1), Weight: 1})
+
+	moved := 0
+	for _, id := range ids {
+		group, _, done := r.Route(id)
+		done()
+		if group != before[id] {
+			moved++
+		}
+	}
+
+	rate := float64(moved) / float64(numIDs)
+	expected := 1.0 / float64(startGroups+1)
+	if rate > expected*2 {
+		t.Fatalf("Add moved %.1f%% of keys, want at most twice the ~%.1f%% the new backend takes", rate*100, expected*100)
+	}
+}
+
+// TestRouterCapacityScalesWithWeight exercises loadStatsLocked and
+// capacityLocked directly: a backend weighted 3x another should get roughly
+// 3x the in-flight capacity for the same total load, not an identical flat
+// cap.
+func TestRouterCapacityScalesWithWeight(t *testing.T) {
+	r := NewRouter([]Backend{{Name: "light", Weight: 1}, {Name: "heavy", Weight: 3}}, WithLoadEpsilon(0))
+
+	r.mu.Lock()
+	*r.load["light"] = 10
+	*r.load["heavy"] = 10
+	r.mu.Unlock()
+
+	r.mu.RLock()
+	bounded, avg, meanWeight := r.loadStatsLocked()
+	lightCap := r.capacityLocked("light", avg, meanWeight)
+	heavyCap := r.capacityLocked("heavy", avg, meanWeight)
+	r.mu.RUnlock()
+
+	if !bounded {
+		t.Fatal("expected bounded-load tracking to be enabled")
+	}
+	if lightCap != 5 {
+		t.Fatalf("light (weight 1, meanWeight 2) capacity = %d, want 5", lightCap)
+	}
+	if heavyCap != 15 {
+		t.Fatalf("heavy (weight 3, meanWeight 2) capacity = %d, want 15", heavyCap)
+	}
+}
+
+// TestRouterBoundedLoadSkipsOverloadedBackend checks that Route actually
+// honors capacityLocked's verdict: a backend already far over its capacity
+// is skipped in favor of the next candidate on the ring.
+func TestRouterBoundedLoadSkipsOverloadedBackend(t *testing.T) {
+	r := NewRouter([]Backend{{Name: "a", Weight: 1}, {Name: "b", Weight: 1}}, WithLoadEpsilon(0))
+
+	r.mu.Lock()
+	*r.load["a"] = 1_000_000
+	r.mu.Unlock()
+
+	for i := 0; i < 50; i++ {
+		group, _, done := r.Route(fmt.Sprintf("req-%d", i))
+		done()
+		if group == "a" {
+			t.Fatalf("request %d routed to saturated backend %q", i, group)
+		}
+	}
+}